@@ -0,0 +1,248 @@
+package nats
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/Jeffail/shutdown"
+)
+
+const (
+	natsKVFieldWatch          = "watch"
+	natsKVFieldIncludeHistory = "include_history"
+	natsKVFieldIgnoreDeletes  = "ignore_deletes"
+
+	natsKVMetaOperation = "nats_kv_operation"
+	natsKVMetaRevision  = "nats_kv_revision"
+	natsKVMetaKey       = "nats_kv_key"
+	natsKVMetaBucket    = "nats_kv_bucket"
+)
+
+func natsJetStreamKVInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Stable().
+		Categories("Services").
+		Version("4.27.0").
+		Summary("Reads messages from a NATS JetStream Key-Value bucket.").
+		Description(connectionNameDescription() + authDescription()).
+		Fields(connectionHeadFields()...).
+		Field(service.NewStringField(natsKVFieldBucket).
+			Description("The name of the KV bucket to read from.")).
+		Field(service.NewStringField(natsKVFieldKey).
+			Description("A key or key wildcard pattern to watch or replay. Defaults to all keys.").
+			Default(">")).
+		Field(service.NewBoolField(natsKVFieldWatch).
+			Description("When `true` the bucket is watched for live changes, emitting a message per create/update/delete. When `false` the `"+natsKVFieldKey+"` history is replayed once and then the input ends.").
+			Default(true)).
+		Field(service.NewBoolField(natsKVFieldIncludeHistory).
+			Description("When watching, also emit the existing values currently in the bucket before switching to live updates.").
+			Default(false)).
+		Field(service.NewBoolField(natsKVFieldIgnoreDeletes).
+			Description("When watching, suppress delete and purge events.").
+			Default(false)).
+		Fields(connectionTailFields()...)
+}
+
+func init() {
+	err := service.RegisterInput(
+		"jetstream_kv", natsJetStreamKVInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			return newJetStreamKVReaderFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type jetStreamKVInput struct {
+	connDetails    connectionDetails
+	bucket         string
+	key            string
+	watch          bool
+	includeHistory bool
+	ignoreDeletes  bool
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	kv       jetstream.KeyValue
+	watcher  jetstream.KeyWatcher
+
+	entries <-chan jetstream.KeyValueEntry
+
+	shutSig *shutdown.Signaller
+
+	pcid string
+}
+
+func newJetStreamKVReaderFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*jetStreamKVInput, error) {
+	i := jetStreamKVInput{
+		log:     mgr.Logger(),
+		shutSig: shutdown.NewSignaller(),
+		pcid:    uuid.New().String(),
+	}
+
+	var err error
+	if i.connDetails, err = connectionDetailsFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+
+	if i.bucket, err = conf.FieldString(natsKVFieldBucket); err != nil {
+		return nil, err
+	}
+
+	if i.key, err = conf.FieldString(natsKVFieldKey); err != nil {
+		return nil, err
+	}
+
+	if i.watch, err = conf.FieldBool(natsKVFieldWatch); err != nil {
+		return nil, err
+	}
+
+	if i.includeHistory, err = conf.FieldBool(natsKVFieldIncludeHistory); err != nil {
+		return nil, err
+	}
+
+	if i.ignoreDeletes, err = conf.FieldBool(natsKVFieldIgnoreDeletes); err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *jetStreamKVInput) Connect(ctx context.Context) (err error) {
+	i.connMut.Lock()
+	defer i.connMut.Unlock()
+
+	if i.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var js jetstream.JetStream
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			_ = pool.Release(i.pcid, i.connDetails)
+		}
+	}()
+
+	if natsConn, err = pool.Get(ctx, i.pcid, i.connDetails); err != nil {
+		return err
+	}
+
+	if js, err = jetstream.New(natsConn); err != nil {
+		return err
+	}
+
+	var kv jetstream.KeyValue
+	if kv, err = js.KeyValue(ctx, i.bucket); err != nil {
+		return err
+	}
+
+	if i.watch {
+		var opts []jetstream.WatchOpt
+		if !i.includeHistory {
+			opts = append(opts, jetstream.UpdatesOnly())
+		}
+		if i.ignoreDeletes {
+			opts = append(opts, jetstream.IgnoreDeletes())
+		}
+
+		var watcher jetstream.KeyWatcher
+		if watcher, err = kv.Watch(ctx, i.key, opts...); err != nil {
+			return err
+		}
+		i.watcher = watcher
+		i.entries = watcher.Updates()
+	} else {
+		var history []jetstream.KeyValueEntry
+		if history, err = kv.History(ctx, i.key); err != nil {
+			return err
+		}
+		ch := make(chan jetstream.KeyValueEntry, len(history)+1)
+		for _, e := range history {
+			ch <- e
+		}
+		close(ch)
+		i.entries = ch
+	}
+
+	i.natsConn = natsConn
+	i.kv = kv
+	return nil
+}
+
+func (i *jetStreamKVInput) disconnect() {
+	i.connMut.Lock()
+	defer i.connMut.Unlock()
+
+	if i.watcher != nil {
+		_ = i.watcher.Stop()
+		i.watcher = nil
+	}
+	if i.natsConn != nil {
+		_ = pool.Release(i.pcid, i.connDetails)
+		i.natsConn = nil
+	}
+	i.kv = nil
+	i.entries = nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *jetStreamKVInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	i.connMut.Lock()
+	entries := i.entries
+	i.connMut.Unlock()
+	if entries == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	select {
+	case entry, open := <-entries:
+		if !open {
+			return nil, nil, service.ErrEndOfInput
+		}
+		// A nil entry marks the end of the initial state dump when watching
+		// with history included; skip it and wait for the next one.
+		if entry == nil {
+			return i.Read(ctx)
+		}
+
+		msg := service.NewMessage(entry.Value())
+		msg.MetaSetMut(natsKVMetaOperation, entry.Operation().String())
+		msg.MetaSetMut(natsKVMetaRevision, entry.Revision())
+		msg.MetaSetMut(natsKVMetaKey, entry.Key())
+		msg.MetaSetMut(natsKVMetaBucket, entry.Bucket())
+
+		return msg, func(ctx context.Context, err error) error {
+			return nil
+		}, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (i *jetStreamKVInput) Close(ctx context.Context) error {
+	go func() {
+		i.disconnect()
+		i.shutSig.TriggerHasStopped()
+	}()
+	select {
+	case <-i.shutSig.HasStoppedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/Jeffail/shutdown"
+)
+
+const (
+	natsKVFieldBucket           = "bucket"
+	natsKVFieldKey              = "key"
+	natsKVFieldExpectedRevision = "expected_revision"
+)
+
+func natsJetStreamKVOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Stable().
+		Categories("Services").
+		Version("4.27.0").
+		Summary("Write messages to a NATS JetStream Key-Value bucket.").
+		Description(connectionNameDescription() + authDescription()).
+		Fields(connectionHeadFields()...).
+		Field(service.NewStringField(natsKVFieldBucket).
+			Description("The name of the KV bucket to write to.")).
+		Field(service.NewInterpolatedStringField(natsKVFieldKey).
+			Description("The key to write each message under.").
+			Example("user.${! json(\"id\") }")).
+		Field(service.NewInterpolatedStringField(natsKVFieldExpectedRevision).
+			Description("An optional expected last revision for the key, used to perform a compare-and-swap update via `Update` instead of an unconditional `Put`. Leave empty to always overwrite.").
+			Optional()).
+		Field(service.NewOutputMaxInFlightField().Default(1024)).
+		Fields(connectionTailFields()...)
+}
+
+func init() {
+	err := service.RegisterOutput(
+		"jetstream_kv", natsJetStreamKVOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.Output, maxInFlight int, err error) {
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newJetStreamKVWriterFromConfig(conf, mgr)
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type jetStreamKVOutput struct {
+	connDetails      connectionDetails
+	bucket           string
+	keyStr           *service.InterpolatedString
+	expectedRevision *service.InterpolatedString
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	kv       jetstream.KeyValue
+
+	shutSig *shutdown.Signaller
+
+	pcid string
+}
+
+func newJetStreamKVWriterFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*jetStreamKVOutput, error) {
+	o := jetStreamKVOutput{
+		log:     mgr.Logger(),
+		shutSig: shutdown.NewSignaller(),
+		pcid:    uuid.New().String(),
+	}
+
+	var err error
+	if o.connDetails, err = connectionDetailsFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+
+	if o.bucket, err = conf.FieldString(natsKVFieldBucket); err != nil {
+		return nil, err
+	}
+
+	if o.keyStr, err = conf.FieldInterpolatedString(natsKVFieldKey); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(natsKVFieldExpectedRevision) {
+		if o.expectedRevision, err = conf.FieldInterpolatedString(natsKVFieldExpectedRevision); err != nil {
+			return nil, err
+		}
+	}
+
+	return &o, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (o *jetStreamKVOutput) Connect(ctx context.Context) (err error) {
+	o.connMut.Lock()
+	defer o.connMut.Unlock()
+
+	if o.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var js jetstream.JetStream
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			_ = pool.Release(o.pcid, o.connDetails)
+		}
+	}()
+
+	if natsConn, err = pool.Get(ctx, o.pcid, o.connDetails); err != nil {
+		return err
+	}
+
+	if js, err = jetstream.New(natsConn); err != nil {
+		return err
+	}
+
+	var kv jetstream.KeyValue
+	if kv, err = js.KeyValue(ctx, o.bucket); err != nil {
+		return err
+	}
+
+	o.natsConn = natsConn
+	o.kv = kv
+	return nil
+}
+
+func (o *jetStreamKVOutput) disconnect() {
+	o.connMut.Lock()
+	defer o.connMut.Unlock()
+
+	if o.natsConn != nil {
+		_ = pool.Release(o.pcid, o.connDetails)
+		o.natsConn = nil
+	}
+	o.kv = nil
+}
+
+//------------------------------------------------------------------------------
+
+func (o *jetStreamKVOutput) Write(ctx context.Context, msg *service.Message) error {
+	o.connMut.Lock()
+	kv := o.kv
+	o.connMut.Unlock()
+	if kv == nil {
+		return service.ErrNotConnected
+	}
+
+	key, err := o.keyStr.TryString(msg)
+	if err != nil {
+		return fmt.Errorf(`failed string interpolation on field "key": %w`, err)
+	}
+
+	data, err := msg.AsBytes()
+	if err != nil {
+		return err
+	}
+
+	if o.expectedRevision != nil {
+		revStr, err := o.expectedRevision.TryString(msg)
+		if err != nil {
+			return fmt.Errorf(`failed string interpolation on field "expected_revision": %w`, err)
+		}
+		if revStr != "" {
+			var rev uint64
+			if _, err := fmt.Sscanf(revStr, "%d", &rev); err != nil {
+				return fmt.Errorf("invalid expected_revision %q: %w", revStr, err)
+			}
+			_, err = kv.Update(ctx, key, data, rev)
+			return err
+		}
+	}
+
+	_, err = kv.Put(ctx, key, data)
+	return err
+}
+
+func (o *jetStreamKVOutput) Close(ctx context.Context) error {
+	go func() {
+		o.disconnect()
+		o.shutSig.TriggerHasStopped()
+	}()
+	select {
+	case <-o.shutSig.HasStoppedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package nats
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/Jeffail/shutdown"
+)
+
+const (
+	natsObjectFieldWatch        = "watch"
+	natsObjectFieldMaxChunkSize = "max_chunk_bytes"
+
+	natsObjectMetaName       = "nats_object_name"
+	natsObjectMetaBucket     = "nats_object_bucket"
+	natsObjectMetaDigest     = "nats_object_digest"
+	natsObjectMetaSize       = "nats_object_size"
+	natsObjectMetaChunkIndex = "nats_object_chunk_index"
+	natsObjectMetaChunkCount = "nats_object_chunk_count"
+)
+
+func natsJetStreamObjectInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Stable().
+		Categories("Services").
+		Version("4.27.0").
+		Summary("Reads objects from a NATS JetStream Object Store bucket.").
+		Description(connectionNameDescription() + authDescription()).
+		Fields(connectionHeadFields()...).
+		Field(service.NewStringField(natsObjectFieldBucket).
+			Description("The name of the object store bucket to read from.")).
+		Field(service.NewStringField(natsObjectFieldName).
+			Description("The name of a single object to read. When set, `"+natsObjectFieldWatch+"` is ignored and the input ends once the object has been read.").
+			Optional()).
+		Field(service.NewBoolField(natsObjectFieldWatch).
+			Description("Watch the bucket for new and updated objects, emitting each one as it appears.").
+			Default(true)).
+		Field(service.NewIntField(natsObjectFieldMaxChunkSize).
+			Description("When set, objects larger than this size are split across multiple messages of at most this many bytes, each carrying `"+natsObjectMetaChunkIndex+"` and `"+natsObjectMetaChunkCount+"` metadata.").
+			Optional()).
+		Fields(connectionTailFields()...)
+}
+
+func init() {
+	err := service.RegisterInput(
+		"jetstream_object", natsJetStreamObjectInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			return newJetStreamObjectReaderFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type jetStreamObjectInput struct {
+	connDetails   connectionDetails
+	bucket        string
+	name          string
+	watch         bool
+	maxChunkBytes int
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	store    jetstream.ObjectStore
+	watcher  jetstream.ObjectWatcher
+
+	updates <-chan *jetstream.ObjectInfo
+	pending []*service.Message
+
+	shutSig *shutdown.Signaller
+
+	pcid string
+}
+
+func newJetStreamObjectReaderFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*jetStreamObjectInput, error) {
+	i := jetStreamObjectInput{
+		log:     mgr.Logger(),
+		shutSig: shutdown.NewSignaller(),
+		pcid:    uuid.New().String(),
+	}
+
+	var err error
+	if i.connDetails, err = connectionDetailsFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+
+	if i.bucket, err = conf.FieldString(natsObjectFieldBucket); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(natsObjectFieldName) {
+		if i.name, err = conf.FieldString(natsObjectFieldName); err != nil {
+			return nil, err
+		}
+	}
+
+	if i.watch, err = conf.FieldBool(natsObjectFieldWatch); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(natsObjectFieldMaxChunkSize) {
+		if i.maxChunkBytes, err = conf.FieldInt(natsObjectFieldMaxChunkSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return &i, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *jetStreamObjectInput) Connect(ctx context.Context) (err error) {
+	i.connMut.Lock()
+	defer i.connMut.Unlock()
+
+	if i.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var js jetstream.JetStream
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			_ = pool.Release(i.pcid, i.connDetails)
+		}
+	}()
+
+	if natsConn, err = pool.Get(ctx, i.pcid, i.connDetails); err != nil {
+		return err
+	}
+
+	if js, err = jetstream.New(natsConn); err != nil {
+		return err
+	}
+
+	var store jetstream.ObjectStore
+	if store, err = js.ObjectStore(ctx, i.bucket); err != nil {
+		return err
+	}
+
+	if i.name == "" && i.watch {
+		var watcher jetstream.ObjectWatcher
+		if watcher, err = store.Watch(ctx); err != nil {
+			return err
+		}
+		i.watcher = watcher
+		i.updates = watcher.Updates()
+	}
+
+	i.natsConn = natsConn
+	i.store = store
+	return nil
+}
+
+func (i *jetStreamObjectInput) disconnect() {
+	i.connMut.Lock()
+	defer i.connMut.Unlock()
+
+	if i.watcher != nil {
+		_ = i.watcher.Stop()
+		i.watcher = nil
+	}
+	if i.natsConn != nil {
+		_ = pool.Release(i.pcid, i.connDetails)
+		i.natsConn = nil
+	}
+	i.store = nil
+	i.updates = nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *jetStreamObjectInput) readObject(ctx context.Context, store jetstream.ObjectStore, name string) ([]*service.Message, error) {
+	res, err := store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	data, err := io.ReadAll(res)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := res.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	if i.maxChunkBytes <= 0 || len(data) <= i.maxChunkBytes {
+		msg := service.NewMessage(data)
+		msg.MetaSetMut(natsObjectMetaName, info.Name)
+		msg.MetaSetMut(natsObjectMetaBucket, info.Bucket)
+		msg.MetaSetMut(natsObjectMetaDigest, info.Digest)
+		msg.MetaSetMut(natsObjectMetaSize, info.Size)
+		return []*service.Message{msg}, nil
+	}
+
+	var chunks []*service.Message
+	total := (len(data) + i.maxChunkBytes - 1) / i.maxChunkBytes
+	for idx := 0; idx < total; idx++ {
+		start := idx * i.maxChunkBytes
+		end := start + i.maxChunkBytes
+		if end > len(data) {
+			end = len(data)
+		}
+		msg := service.NewMessage(data[start:end])
+		msg.MetaSetMut(natsObjectMetaName, info.Name)
+		msg.MetaSetMut(natsObjectMetaBucket, info.Bucket)
+		msg.MetaSetMut(natsObjectMetaDigest, info.Digest)
+		msg.MetaSetMut(natsObjectMetaSize, info.Size)
+		msg.MetaSetMut(natsObjectMetaChunkIndex, idx)
+		msg.MetaSetMut(natsObjectMetaChunkCount, total)
+		chunks = append(chunks, msg)
+	}
+	return chunks, nil
+}
+
+func (i *jetStreamObjectInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	i.connMut.Lock()
+	store := i.store
+	updates := i.updates
+	i.connMut.Unlock()
+	if store == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	nackFn := func(ctx context.Context, err error) error { return nil }
+
+	if len(i.pending) > 0 {
+		msg := i.pending[0]
+		i.pending = i.pending[1:]
+		return msg, nackFn, nil
+	}
+
+	if i.name != "" {
+		msgs, err := i.readObject(ctx, store, i.name)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.name = ""
+		i.pending = msgs[1:]
+		return msgs[0], nackFn, nil
+	}
+
+	if updates == nil {
+		return nil, nil, service.ErrEndOfInput
+	}
+
+	for {
+		select {
+		case info, open := <-updates:
+			if !open {
+				return nil, nil, service.ErrEndOfInput
+			}
+			if info == nil || info.Deleted {
+				continue
+			}
+			msgs, err := i.readObject(ctx, store, info.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			i.pending = msgs[1:]
+			return msgs[0], nackFn, nil
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+func (i *jetStreamObjectInput) Close(ctx context.Context) error {
+	go func() {
+		i.disconnect()
+		i.shutSig.TriggerHasStopped()
+	}()
+	select {
+	case <-i.shutSig.HasStoppedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/Jeffail/shutdown"
+)
+
+const (
+	natsObjectFieldBucket      = "bucket"
+	natsObjectFieldName        = "name"
+	natsObjectFieldDescription = "description"
+)
+
+func natsJetStreamObjectOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Stable().
+		Categories("Services").
+		Version("4.27.0").
+		Summary("Write messages as objects to a NATS JetStream Object Store bucket.").
+		Description(connectionNameDescription() + authDescription()).
+		Fields(connectionHeadFields()...).
+		Field(service.NewStringField(natsObjectFieldBucket).
+			Description("The name of the object store bucket to write to.")).
+		Field(service.NewInterpolatedStringField(natsObjectFieldName).
+			Description("The name to store the object under.").
+			Example("${! json(\"file.name\") }")).
+		Field(service.NewInterpolatedStringField(natsObjectFieldDescription).
+			Description("An optional description to attach to the stored object.").
+			Optional()).
+		Field(service.NewInterpolatedStringMapField("headers").
+			Description("Explicit headers to attach to the stored object.").
+			Default(map[string]any{})).
+		Field(service.NewMetadataFilterField("metadata").
+			Description("Determine which (if any) metadata values should be attached to the object as headers.").
+			Optional()).
+		Field(service.NewOutputMaxInFlightField().Default(64)).
+		Fields(connectionTailFields()...)
+}
+
+func init() {
+	err := service.RegisterOutput(
+		"jetstream_object", natsJetStreamObjectOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (out service.Output, maxInFlight int, err error) {
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			out, err = newJetStreamObjectWriterFromConfig(conf, mgr)
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type jetStreamObjectOutput struct {
+	connDetails connectionDetails
+	bucket      string
+	nameStr     *service.InterpolatedString
+	descStr     *service.InterpolatedString
+	headers     map[string]*service.InterpolatedString
+	metaFilter  *service.MetadataFilter
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	store    jetstream.ObjectStore
+
+	shutSig *shutdown.Signaller
+
+	pcid string
+}
+
+func newJetStreamObjectWriterFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*jetStreamObjectOutput, error) {
+	o := jetStreamObjectOutput{
+		log:     mgr.Logger(),
+		shutSig: shutdown.NewSignaller(),
+		pcid:    uuid.New().String(),
+	}
+
+	var err error
+	if o.connDetails, err = connectionDetailsFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+
+	if o.bucket, err = conf.FieldString(natsObjectFieldBucket); err != nil {
+		return nil, err
+	}
+
+	if o.nameStr, err = conf.FieldInterpolatedString(natsObjectFieldName); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(natsObjectFieldDescription) {
+		if o.descStr, err = conf.FieldInterpolatedString(natsObjectFieldDescription); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.headers, err = conf.FieldInterpolatedStringMap("headers"); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains("metadata") {
+		if o.metaFilter, err = conf.FieldMetadataFilter("metadata"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &o, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (o *jetStreamObjectOutput) Connect(ctx context.Context) (err error) {
+	o.connMut.Lock()
+	defer o.connMut.Unlock()
+
+	if o.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var js jetstream.JetStream
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			_ = pool.Release(o.pcid, o.connDetails)
+		}
+	}()
+
+	if natsConn, err = pool.Get(ctx, o.pcid, o.connDetails); err != nil {
+		return err
+	}
+
+	if js, err = jetstream.New(natsConn); err != nil {
+		return err
+	}
+
+	var store jetstream.ObjectStore
+	if store, err = js.ObjectStore(ctx, o.bucket); err != nil {
+		return err
+	}
+
+	o.natsConn = natsConn
+	o.store = store
+	return nil
+}
+
+func (o *jetStreamObjectOutput) disconnect() {
+	o.connMut.Lock()
+	defer o.connMut.Unlock()
+
+	if o.natsConn != nil {
+		_ = pool.Release(o.pcid, o.connDetails)
+		o.natsConn = nil
+	}
+	o.store = nil
+}
+
+//------------------------------------------------------------------------------
+
+func (o *jetStreamObjectOutput) Write(ctx context.Context, msg *service.Message) error {
+	o.connMut.Lock()
+	store := o.store
+	o.connMut.Unlock()
+	if store == nil {
+		return service.ErrNotConnected
+	}
+
+	name, err := o.nameStr.TryString(msg)
+	if err != nil {
+		return fmt.Errorf(`failed string interpolation on field "name": %w`, err)
+	}
+
+	meta := jetstream.ObjectMeta{
+		Name:    name,
+		Headers: nats.Header{},
+	}
+
+	if o.descStr != nil {
+		if meta.Description, err = o.descStr.TryString(msg); err != nil {
+			return fmt.Errorf(`failed string interpolation on field "description": %w`, err)
+		}
+	}
+
+	for k, v := range o.headers {
+		value, err := v.TryString(msg)
+		if err != nil {
+			return fmt.Errorf(`failed string interpolation on header %q: %w`, k, err)
+		}
+		meta.Headers.Add(k, value)
+	}
+	_ = o.metaFilter.Walk(msg, func(key, value string) error {
+		meta.Headers.Add(key, value)
+		return nil
+	})
+
+	data, err := msg.AsBytes()
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Put(ctx, meta, bytes.NewReader(data))
+	return err
+}
+
+func (o *jetStreamObjectOutput) Close(ctx context.Context) error {
+	go func() {
+		o.disconnect()
+		o.shutSig.TriggerHasStopped()
+	}()
+	select {
+	case <-o.shutSig.HasStoppedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
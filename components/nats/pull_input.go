@@ -0,0 +1,458 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/Jeffail/shutdown"
+)
+
+const (
+	natsPullFieldStream         = "stream"
+	natsPullFieldDurable        = "durable"
+	natsPullFieldFilterSubjects = "filter_subjects"
+	natsPullFieldDeliverPolicy  = "deliver_policy"
+	natsPullFieldAckPolicy      = "ack_policy"
+	natsPullFieldAckWait        = "ack_wait"
+	natsPullFieldMaxAckPending  = "max_ack_pending"
+	natsPullFieldMaxDeliver     = "max_deliver"
+	natsPullFieldBackoff        = "backoff"
+	natsPullFieldBatchSize      = "batch_size"
+	natsPullFieldExpires        = "expires"
+	natsPullFieldOptStartSeq    = "opt_start_seq"
+	natsPullFieldOptStartTime   = "opt_start_time"
+
+	natsPullMetaSubject    = "nats_subject"
+	natsPullMetaSequence   = "nats_sequence"
+	natsPullMetaNumDeliver = "nats_num_delivered"
+	natsPullMetaStream     = "nats_stream"
+
+	natsPullInitialBackoff = 500 * time.Millisecond
+	natsPullMaxBackoff     = 30 * time.Second
+)
+
+func natsJetStreamPullInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Stable().
+		Categories("Services").
+		Version("4.27.0").
+		Summary("Consume messages from a NATS JetStream stream using a durable pull consumer.").
+		Description(connectionNameDescription() + authDescription()).
+		Fields(connectionHeadFields()...).
+		Field(service.NewStringField(natsPullFieldStream).
+			Description("The name of the stream to consume from.")).
+		Field(service.NewStringField(natsPullFieldDurable).
+			Description("The durable name of the pull consumer. The consumer is created if it does not already exist.")).
+		Field(service.NewStringListField(natsPullFieldFilterSubjects).
+			Description("An optional list of subjects to filter the consumer to.").
+			Default([]any{}).
+			Optional()).
+		Field(service.NewStringField(natsPullFieldDeliverPolicy).
+			Description("The point in the stream from which to start delivering messages. `by_start_seq` requires `"+natsPullFieldOptStartSeq+"` and `by_start_time` requires `"+natsPullFieldOptStartTime+"`.").
+			Examples("all", "new", "by_start_seq", "by_start_time").
+			Default("all")).
+		Field(service.NewIntField(natsPullFieldOptStartSeq).
+			Description("The stream sequence to start delivering from. Required when `"+natsPullFieldDeliverPolicy+"` is `by_start_seq`.").
+			Optional()).
+		Field(service.NewStringField(natsPullFieldOptStartTime).
+			Description("An RFC 3339 timestamp to start delivering from. Required when `"+natsPullFieldDeliverPolicy+"` is `by_start_time`.").
+			Example("2024-01-01T00:00:00Z").
+			Optional()).
+		Field(service.NewStringField(natsPullFieldAckPolicy).
+			Description("The acknowledgement policy of the consumer.").
+			Examples("explicit", "none", "all").
+			Default("explicit")).
+		Field(service.NewDurationField(natsPullFieldAckWait).
+			Description("How long to wait for an ack before the message is redelivered.").
+			Default("30s")).
+		Field(service.NewIntField(natsPullFieldMaxAckPending).
+			Description("The maximum number of outstanding unacknowledged messages.").
+			Default(1000)).
+		Field(service.NewIntField(natsPullFieldMaxDeliver).
+			Description("The maximum number of delivery attempts for a message before it is treated as a terminal failure.").
+			Default(-1)).
+		Field(service.NewStringListField(natsPullFieldBackoff).
+			Description("An optional sequence of redelivery backoff durations.").
+			Example([]any{"1s", "5s", "30s"}).
+			Default([]any{}).
+			Optional()).
+		Field(service.NewIntField(natsPullFieldBatchSize).
+			Description("The maximum number of messages to fetch in a single pull request.").
+			Default(100)).
+		Field(service.NewDurationField(natsPullFieldExpires).
+			Description("The maximum amount of time to wait for a fetch to fill its batch.").
+			Default("5s")).
+		Fields(connectionTailFields()...)
+}
+
+func init() {
+	err := service.RegisterInput(
+		"jetstream_pull", natsJetStreamPullInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			return newJetStreamPullReaderFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type jetStreamPullInput struct {
+	connDetails    connectionDetails
+	stream         string
+	durable        string
+	filterSubjects []string
+	deliverPolicy  string
+	optStartSeq    uint64
+	optStartTime   *time.Time
+	ackPolicy      string
+	ackWait        time.Duration
+	maxAckPending  int
+	maxDeliver     int
+	backoff        []time.Duration
+	batchSize      int
+	expires        time.Duration
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	consumer jetstream.Consumer
+
+	msgs chan jetstream.Msg
+	errs chan error
+
+	shutSig *shutdown.Signaller
+
+	pcid string
+}
+
+func newJetStreamPullReaderFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*jetStreamPullInput, error) {
+	i := jetStreamPullInput{
+		log:     mgr.Logger(),
+		shutSig: shutdown.NewSignaller(),
+		pcid:    uuid.New().String(),
+	}
+
+	var err error
+	if i.connDetails, err = connectionDetailsFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+
+	if i.stream, err = conf.FieldString(natsPullFieldStream); err != nil {
+		return nil, err
+	}
+
+	if i.durable, err = conf.FieldString(natsPullFieldDurable); err != nil {
+		return nil, err
+	}
+
+	if i.filterSubjects, err = conf.FieldStringList(natsPullFieldFilterSubjects); err != nil {
+		return nil, err
+	}
+
+	if i.deliverPolicy, err = conf.FieldString(natsPullFieldDeliverPolicy); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(natsPullFieldOptStartSeq) {
+		var seq int
+		if seq, err = conf.FieldInt(natsPullFieldOptStartSeq); err != nil {
+			return nil, err
+		}
+		i.optStartSeq = uint64(seq)
+	}
+
+	if conf.Contains(natsPullFieldOptStartTime) {
+		var startTimeStr string
+		if startTimeStr, err = conf.FieldString(natsPullFieldOptStartTime); err != nil {
+			return nil, err
+		}
+		startTime, err := time.Parse(time.RFC3339, startTimeStr)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse field "%s": %w`, natsPullFieldOptStartTime, err)
+		}
+		i.optStartTime = &startTime
+	}
+
+	if i.deliverPolicy == "by_start_seq" && i.optStartSeq == 0 {
+		return nil, fmt.Errorf("%s is required when %s is \"by_start_seq\"", natsPullFieldOptStartSeq, natsPullFieldDeliverPolicy)
+	}
+	if i.deliverPolicy == "by_start_time" && i.optStartTime == nil {
+		return nil, fmt.Errorf("%s is required when %s is \"by_start_time\"", natsPullFieldOptStartTime, natsPullFieldDeliverPolicy)
+	}
+
+	if i.ackPolicy, err = conf.FieldString(natsPullFieldAckPolicy); err != nil {
+		return nil, err
+	}
+
+	if i.ackWait, err = conf.FieldDuration(natsPullFieldAckWait); err != nil {
+		return nil, err
+	}
+
+	if i.maxAckPending, err = conf.FieldInt(natsPullFieldMaxAckPending); err != nil {
+		return nil, err
+	}
+
+	if i.maxDeliver, err = conf.FieldInt(natsPullFieldMaxDeliver); err != nil {
+		return nil, err
+	}
+
+	backoffStrs, err := conf.FieldStringList(natsPullFieldBackoff)
+	if err != nil {
+		return nil, err
+	}
+	if i.backoff, err = parseBackoffDurations(backoffStrs); err != nil {
+		return nil, err
+	}
+
+	if i.batchSize, err = conf.FieldInt(natsPullFieldBatchSize); err != nil {
+		return nil, err
+	}
+
+	if i.expires, err = conf.FieldDuration(natsPullFieldExpires); err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func deliverPolicyFromString(s string) jetstream.DeliverPolicy {
+	switch s {
+	case "new":
+		return jetstream.DeliverNewPolicy
+	case "by_start_seq":
+		return jetstream.DeliverByStartSequencePolicy
+	case "by_start_time":
+		return jetstream.DeliverByStartTimePolicy
+	default:
+		return jetstream.DeliverAllPolicy
+	}
+}
+
+func ackPolicyFromString(s string) jetstream.AckPolicy {
+	switch s {
+	case "none":
+		return jetstream.AckNonePolicy
+	case "all":
+		return jetstream.AckAllPolicy
+	default:
+		return jetstream.AckExplicitPolicy
+	}
+}
+
+// parseBackoffDurations parses each entry of a string list field into a
+// time.Duration, in order.
+func parseBackoffDurations(strs []string) ([]time.Duration, error) {
+	if len(strs) == 0 {
+		return nil, nil
+	}
+	durations := make([]time.Duration, len(strs))
+	for idx, s := range strs {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q entry %q: %w", natsPullFieldBackoff, s, err)
+		}
+		durations[idx] = d
+	}
+	return durations, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *jetStreamPullInput) Connect(ctx context.Context) (err error) {
+	i.connMut.Lock()
+	defer i.connMut.Unlock()
+
+	if i.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var js jetstream.JetStream
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			_ = pool.Release(i.pcid, i.connDetails)
+		}
+	}()
+
+	if natsConn, err = pool.Get(ctx, i.pcid, i.connDetails); err != nil {
+		return err
+	}
+
+	if js, err = jetstream.New(natsConn); err != nil {
+		return err
+	}
+
+	var stream jetstream.Stream
+	if stream, err = js.Stream(ctx, i.stream); err != nil {
+		return err
+	}
+
+	cfg := jetstream.ConsumerConfig{
+		Durable:        i.durable,
+		FilterSubjects: i.filterSubjects,
+		DeliverPolicy:  deliverPolicyFromString(i.deliverPolicy),
+		OptStartSeq:    i.optStartSeq,
+		AckPolicy:      ackPolicyFromString(i.ackPolicy),
+		AckWait:        i.ackWait,
+		MaxAckPending:  i.maxAckPending,
+		MaxDeliver:     i.maxDeliver,
+		BackOff:        i.backoff,
+	}
+	if i.optStartTime != nil {
+		cfg.OptStartTime = i.optStartTime
+	}
+
+	var consumer jetstream.Consumer
+	if consumer, err = stream.CreateOrUpdateConsumer(ctx, cfg); err != nil {
+		return err
+	}
+
+	i.natsConn = natsConn
+	i.consumer = consumer
+	i.msgs = make(chan jetstream.Msg, i.batchSize)
+	i.errs = make(chan error, 1)
+
+	go i.pullLoop()
+
+	return nil
+}
+
+func (i *jetStreamPullInput) pullLoop() {
+	backoff := natsPullInitialBackoff
+	for {
+		if i.shutSig.IsSoftStopSignalled() {
+			return
+		}
+
+		batch, err := i.consumer.Fetch(i.batchSize, jetstream.FetchMaxWait(i.expires))
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			select {
+			case i.errs <- err:
+			default:
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-i.shutSig.SoftStopChan():
+				return
+			}
+			if backoff < natsPullMaxBackoff {
+				backoff *= 2
+				if backoff > natsPullMaxBackoff {
+					backoff = natsPullMaxBackoff
+				}
+			}
+			continue
+		}
+
+		backoff = natsPullInitialBackoff
+
+		for msg := range batch.Messages() {
+			select {
+			case i.msgs <- msg:
+			case <-i.shutSig.SoftStopChan():
+				return
+			}
+		}
+	}
+}
+
+func (i *jetStreamPullInput) disconnect() {
+	i.connMut.Lock()
+	defer i.connMut.Unlock()
+
+	if i.natsConn != nil {
+		_ = pool.Release(i.pcid, i.connDetails)
+		i.natsConn = nil
+	}
+	i.consumer = nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *jetStreamPullInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	i.connMut.Lock()
+	msgs := i.msgs
+	i.connMut.Unlock()
+	if msgs == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	select {
+	case natsMsg, open := <-msgs:
+		if !open {
+			return nil, nil, service.ErrEndOfInput
+		}
+
+		meta, err := natsMsg.Metadata()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		msg := service.NewMessage(natsMsg.Data())
+		msg.MetaSetMut(natsPullMetaSubject, natsMsg.Subject())
+		msg.MetaSetMut(natsPullMetaSequence, meta.Sequence.Stream)
+		msg.MetaSetMut(natsPullMetaNumDeliver, meta.NumDelivered)
+		msg.MetaSetMut(natsPullMetaStream, meta.Stream)
+		for k, values := range natsMsg.Headers() {
+			for _, v := range values {
+				msg.MetaSetMut(k, v)
+			}
+		}
+
+		return msg, func(ctx context.Context, err error) error {
+			if err == nil {
+				return natsMsg.Ack()
+			}
+
+			if i.maxDeliver > 0 && int(meta.NumDelivered) >= i.maxDeliver {
+				return natsMsg.Term()
+			}
+
+			var delay time.Duration
+			if len(i.backoff) > 0 {
+				idx := int(meta.NumDelivered) - 1
+				if idx >= len(i.backoff) {
+					idx = len(i.backoff) - 1
+				}
+				if idx >= 0 {
+					delay = i.backoff[idx]
+				}
+			}
+			return natsMsg.NakWithDelay(delay)
+		}, nil
+	case err := <-i.errs:
+		return nil, nil, err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (i *jetStreamPullInput) Close(ctx context.Context) error {
+	go func() {
+		i.shutSig.TriggerSoftStop()
+		i.disconnect()
+		i.shutSig.TriggerHasStopped()
+	}()
+	select {
+	case <-i.shutSig.HasStoppedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
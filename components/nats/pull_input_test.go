@@ -0,0 +1,77 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestDeliverPolicyFromString(t *testing.T) {
+	cases := []struct {
+		in   string
+		want jetstream.DeliverPolicy
+	}{
+		{"new", jetstream.DeliverNewPolicy},
+		{"by_start_seq", jetstream.DeliverByStartSequencePolicy},
+		{"by_start_time", jetstream.DeliverByStartTimePolicy},
+		{"all", jetstream.DeliverAllPolicy},
+		{"unknown", jetstream.DeliverAllPolicy},
+	}
+
+	for _, c := range cases {
+		if got := deliverPolicyFromString(c.in); got != c.want {
+			t.Errorf("%q: expected %v, got %v", c.in, c.want, got)
+		}
+	}
+}
+
+func TestAckPolicyFromString(t *testing.T) {
+	cases := []struct {
+		in   string
+		want jetstream.AckPolicy
+	}{
+		{"none", jetstream.AckNonePolicy},
+		{"all", jetstream.AckAllPolicy},
+		{"explicit", jetstream.AckExplicitPolicy},
+		{"unknown", jetstream.AckExplicitPolicy},
+	}
+
+	for _, c := range cases {
+		if got := ackPolicyFromString(c.in); got != c.want {
+			t.Errorf("%q: expected %v, got %v", c.in, c.want, got)
+		}
+	}
+}
+
+func TestParseBackoffDurations(t *testing.T) {
+	got, err := parseBackoffDurations([]string{"1s", "5s", "30s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d durations, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("index %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseBackoffDurationsEmpty(t *testing.T) {
+	got, err := parseBackoffDurations(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no durations, got %v", got)
+	}
+}
+
+func TestParseBackoffDurationsInvalid(t *testing.T) {
+	if _, err := parseBackoffDurations([]string{"not-a-duration"}); err == nil {
+		t.Fatal("expected an error for an invalid duration")
+	}
+}
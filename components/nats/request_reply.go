@@ -0,0 +1,405 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/Jeffail/shutdown"
+)
+
+const (
+	natsRRFieldSubject          = "subject"
+	natsRRFieldTimeout          = "timeout"
+	natsRRFieldHeaders          = "headers"
+	natsRRFieldInjectResponseAs = "inject_response_as"
+	natsRRFieldReplySubject     = "reply_subject"
+	natsRRFieldMaxReplies       = "max_replies"
+
+	natsRRInjectBody            = "body"
+	natsRRInjectMetadata        = "metadata"
+	natsRRInjectJSONFieldPrefix = "json_field:"
+)
+
+func natsRequestReplyConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Stable().
+		Categories("Services").
+		Version("4.27.0").
+		Summary("Perform a synchronous NATS request/reply and attach the response to the message.").
+		Description(connectionNameDescription() + authDescription()).
+		Fields(connectionHeadFields()...).
+		Field(service.NewInterpolatedStringField(natsRRFieldSubject).
+			Description("The subject to issue the request on.").
+			Example("rpc.users.lookup")).
+		Field(service.NewDurationField(natsRRFieldTimeout).
+			Description("The maximum time to wait for a reply.").
+			Default("5s")).
+		Field(service.NewInterpolatedStringMapField(natsRRFieldHeaders).
+			Description("Explicit headers to add to the request.").
+			Default(map[string]any{})).
+		Field(service.NewStringField(natsRRFieldInjectResponseAs).
+			Description("How to attach the reply to the message: `"+natsRRInjectBody+"` replaces the message payload, `"+natsRRInjectMetadata+"` sets it as metadata, or `"+natsRRInjectJSONFieldPrefix+"<path>` parses the reply as JSON and sets it at the given dot-separated field path.").
+			Examples(natsRRInjectBody, natsRRInjectMetadata, natsRRInjectJSONFieldPrefix+"response").
+			Default(natsRRInjectBody)).
+		Field(service.NewInterpolatedStringField(natsRRFieldReplySubject).
+			Description("An optional explicit reply-to subject. When set, the processor subscribes to this subject itself (instead of letting NATS generate an inbox), publishes the request with it as the reply-to, and collects every reply it receives on it before `"+natsRRFieldTimeout+"` elapses (or `"+natsRRFieldMaxReplies+"`, if set). `"+natsRRFieldInjectResponseAs+"` is applied per reply and the results are aggregated into the message (as a JSON array for `"+natsRRInjectBody+"`/`"+natsRRInjectJSONFieldPrefix+"<path>`, or as indexed metadata for `"+natsRRInjectMetadata+"`). Use this for request-many patterns where multiple responders may answer the same request.").
+			Optional()).
+		Field(service.NewIntField(natsRRFieldMaxReplies).
+			Description("When `"+natsRRFieldReplySubject+"` is set, the maximum number of replies to collect before returning early.").
+			Optional()).
+		Fields(connectionTailFields()...)
+}
+
+func init() {
+	err := service.RegisterBatchProcessor(
+		"nats_request_reply", natsRequestReplyConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newNATSRequestReplyFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type natsRequestReply struct {
+	connDetails      connectionDetails
+	subjectStr       *service.InterpolatedString
+	timeout          time.Duration
+	headers          map[string]*service.InterpolatedString
+	injectResponseAs string
+	replySubject     *service.InterpolatedString
+	maxReplies       int
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+
+	shutSig *shutdown.Signaller
+
+	pcid string
+}
+
+func newNATSRequestReplyFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*natsRequestReply, error) {
+	p := natsRequestReply{
+		log:     mgr.Logger(),
+		shutSig: shutdown.NewSignaller(),
+		pcid:    uuid.New().String(),
+	}
+
+	var err error
+	if p.connDetails, err = connectionDetailsFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+
+	if p.subjectStr, err = conf.FieldInterpolatedString(natsRRFieldSubject); err != nil {
+		return nil, err
+	}
+
+	if p.timeout, err = conf.FieldDuration(natsRRFieldTimeout); err != nil {
+		return nil, err
+	}
+
+	if p.headers, err = conf.FieldInterpolatedStringMap(natsRRFieldHeaders); err != nil {
+		return nil, err
+	}
+
+	if p.injectResponseAs, err = conf.FieldString(natsRRFieldInjectResponseAs); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(natsRRFieldReplySubject) {
+		if p.replySubject, err = conf.FieldInterpolatedString(natsRRFieldReplySubject); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Contains(natsRRFieldMaxReplies) {
+		if p.maxReplies, err = conf.FieldInt(natsRRFieldMaxReplies); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (p *natsRequestReply) Connect(ctx context.Context) (err error) {
+	p.connMut.Lock()
+	defer p.connMut.Unlock()
+
+	if p.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	defer func() {
+		if err != nil && natsConn != nil {
+			_ = pool.Release(p.pcid, p.connDetails)
+		}
+	}()
+
+	if natsConn, err = pool.Get(ctx, p.pcid, p.connDetails); err != nil {
+		return err
+	}
+
+	p.natsConn = natsConn
+	return nil
+}
+
+func (p *natsRequestReply) disconnect() {
+	p.connMut.Lock()
+	defer p.connMut.Unlock()
+
+	if p.natsConn != nil {
+		_ = pool.Release(p.pcid, p.connDetails)
+		p.natsConn = nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (p *natsRequestReply) buildRequestMsg(msg *service.Message, subject string) (*nats.Msg, error) {
+	reqMsg := nats.NewMsg(subject)
+
+	data, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	reqMsg.Data = data
+
+	for k, v := range p.headers {
+		value, err := v.TryString(msg)
+		if err != nil {
+			return nil, fmt.Errorf(`failed string interpolation on header %q: %w`, k, err)
+		}
+		reqMsg.Header.Add(k, value)
+	}
+
+	return reqMsg, nil
+}
+
+// setJSONPath assigns value at the given dot-separated path within root,
+// creating intermediate maps as needed, and returns the resulting root.
+func setJSONPath(root any, path []string, value any) any {
+	rootMap, ok := root.(map[string]any)
+	if !ok {
+		rootMap = map[string]any{}
+	}
+
+	if len(path) == 1 {
+		rootMap[path[0]] = value
+		return rootMap
+	}
+
+	child := setJSONPath(rootMap[path[0]], path[1:], value)
+	rootMap[path[0]] = child
+	return rootMap
+}
+
+func (p *natsRequestReply) injectResponse(msg *service.Message, data []byte, header nats.Header) error {
+	switch {
+	case p.injectResponseAs == natsRRInjectBody:
+		msg.SetBytes(data)
+	case p.injectResponseAs == natsRRInjectMetadata:
+		for k, values := range header {
+			for _, v := range values {
+				msg.MetaSetMut(k, v)
+			}
+		}
+		msg.MetaSetMut("nats_request_reply_body", string(data))
+	default:
+		path := strings.TrimPrefix(p.injectResponseAs, natsRRInjectJSONFieldPrefix)
+
+		var respVal any
+		if err := json.Unmarshal(data, &respVal); err != nil {
+			respVal = string(data)
+		}
+
+		root, err := msg.AsStructuredMut()
+		if err != nil {
+			root = map[string]any{}
+		}
+
+		msg.SetStructuredMut(setJSONPath(root, strings.Split(path, "."), respVal))
+	}
+	return nil
+}
+
+// natsReply holds a single reply collected by requestMany, preserving its
+// headers alongside its body so that injectManyResponses can honor
+// inject_response_as per reply.
+type natsReply struct {
+	Data   []byte
+	Header nats.Header
+}
+
+// requestMany subscribes to replyTo, publishes reqMsg (with Reply set to
+// replyTo so responders can address it directly) and collects every reply
+// received before p.timeout elapses or p.maxReplies is reached.
+func (p *natsRequestReply) requestMany(natsConn *nats.Conn, reqMsg *nats.Msg, replyTo string) ([]natsReply, error) {
+	sub, err := natsConn.SubscribeSync(replyTo)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	reqMsg.Reply = replyTo
+	if err := natsConn.PublishMsg(reqMsg); err != nil {
+		return nil, err
+	}
+
+	var replies []natsReply
+	deadline := time.Now().Add(p.timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+
+		reply, err := sub.NextMsg(remaining)
+		if err != nil {
+			break
+		}
+		replies = append(replies, natsReply{Data: reply.Data, Header: reply.Header})
+
+		if p.maxReplies > 0 && len(replies) >= p.maxReplies {
+			break
+		}
+	}
+
+	return replies, nil
+}
+
+// injectManyResponses applies injectResponseAs across every reply collected
+// by requestMany, aggregating them into msg rather than attaching a single
+// response.
+func (p *natsRequestReply) injectManyResponses(msg *service.Message, replies []natsReply) error {
+	decode := func(data []byte) any {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return string(data)
+		}
+		return v
+	}
+
+	switch {
+	case p.injectResponseAs == natsRRInjectBody:
+		bodies := make([]any, len(replies))
+		for idx, r := range replies {
+			bodies[idx] = decode(r.Data)
+		}
+		msg.SetStructuredMut(bodies)
+	case p.injectResponseAs == natsRRInjectMetadata:
+		for idx, r := range replies {
+			for k, values := range r.Header {
+				for _, v := range values {
+					msg.MetaSetMut(fmt.Sprintf("nats_request_reply_%d_%s", idx, k), v)
+				}
+			}
+			msg.MetaSetMut(fmt.Sprintf("nats_request_reply_%d_body", idx), string(r.Data))
+		}
+		msg.MetaSetMut("nats_request_reply_count", len(replies))
+	default:
+		path := strings.TrimPrefix(p.injectResponseAs, natsRRInjectJSONFieldPrefix)
+
+		values := make([]any, len(replies))
+		for idx, r := range replies {
+			values[idx] = decode(r.Data)
+		}
+
+		root, err := msg.AsStructuredMut()
+		if err != nil {
+			root = map[string]any{}
+		}
+		msg.SetStructuredMut(setJSONPath(root, strings.Split(path, "."), values))
+	}
+	return nil
+}
+
+func (p *natsRequestReply) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	p.connMut.Lock()
+	natsConn := p.natsConn
+	p.connMut.Unlock()
+	if natsConn == nil {
+		if err := p.Connect(ctx); err != nil {
+			return nil, err
+		}
+		p.connMut.Lock()
+		natsConn = p.natsConn
+		p.connMut.Unlock()
+	}
+
+	for _, msg := range batch {
+		subject, err := p.subjectStr.TryString(msg)
+		if err != nil {
+			msg.SetError(fmt.Errorf(`failed string interpolation on field "subject": %w`, err))
+			continue
+		}
+
+		reqMsg, err := p.buildRequestMsg(msg, subject)
+		if err != nil {
+			msg.SetError(err)
+			continue
+		}
+
+		if p.replySubject != nil {
+			replyTo, err := p.replySubject.TryString(msg)
+			if err != nil {
+				msg.SetError(fmt.Errorf(`failed string interpolation on field "reply_subject": %w`, err))
+				continue
+			}
+
+			replies, err := p.requestMany(natsConn, reqMsg, replyTo)
+			if err != nil {
+				msg.SetError(err)
+				continue
+			}
+
+			if err := p.injectManyResponses(msg, replies); err != nil {
+				msg.SetError(err)
+			}
+			continue
+		}
+
+		replyCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		reply, err := natsConn.RequestMsgWithContext(replyCtx, reqMsg)
+		cancel()
+		if err != nil {
+			msg.SetError(err)
+			continue
+		}
+
+		if err := p.injectResponse(msg, reply.Data, reply.Header); err != nil {
+			msg.SetError(err)
+		}
+	}
+
+	return []service.MessageBatch{batch}, nil
+}
+
+func (p *natsRequestReply) Close(ctx context.Context) error {
+	go func() {
+		p.disconnect()
+		p.shutSig.TriggerHasStopped()
+	}()
+	select {
+	case <-p.shutSig.HasStoppedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package nats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestSetJSONPath(t *testing.T) {
+	root := setJSONPath(nil, []string{"response"}, "ok")
+	want := map[string]any{"response": "ok"}
+	if !reflect.DeepEqual(root, want) {
+		t.Fatalf("expected %v, got %v", want, root)
+	}
+
+	root = setJSONPath(root, []string{"meta", "status"}, float64(200))
+	want = map[string]any{
+		"response": "ok",
+		"meta":     map[string]any{"status": float64(200)},
+	}
+	if !reflect.DeepEqual(root, want) {
+		t.Fatalf("expected %v, got %v", want, root)
+	}
+}
+
+func TestInjectResponseBody(t *testing.T) {
+	p := &natsRequestReply{injectResponseAs: natsRRInjectBody}
+	msg := service.NewMessage(nil)
+
+	if err := p.injectResponse(msg, []byte(`{"ok":true}`), nats.Header{}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := msg.AsBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"ok":true}` {
+		t.Errorf("expected raw body to be preserved, got %q", got)
+	}
+}
+
+func TestInjectResponseMetadata(t *testing.T) {
+	p := &natsRequestReply{injectResponseAs: natsRRInjectMetadata}
+	msg := service.NewMessage(nil)
+
+	header := nats.Header{"X-Status": []string{"200"}}
+	if err := p.injectResponse(msg, []byte("pong"), header); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := msg.MetaGet("X-Status"); !ok || v != "200" {
+		t.Errorf("expected X-Status metadata %q, got %q (ok=%v)", "200", v, ok)
+	}
+	if v, ok := msg.MetaGet("nats_request_reply_body"); !ok || v != "pong" {
+		t.Errorf("expected nats_request_reply_body metadata %q, got %q (ok=%v)", "pong", v, ok)
+	}
+}
+
+func TestInjectResponseJSONField(t *testing.T) {
+	p := &natsRequestReply{injectResponseAs: natsRRInjectJSONFieldPrefix + "reply.status"}
+	msg := service.NewMessage([]byte(`{"request":"ping"}`))
+
+	if err := p.injectResponse(msg, []byte(`"pong"`), nats.Header{}); err != nil {
+		t.Fatal(err)
+	}
+
+	structured, err := msg.AsStructured()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"request": "ping",
+		"reply":   map[string]any{"status": "pong"},
+	}
+	if !reflect.DeepEqual(structured, want) {
+		t.Fatalf("expected %v, got %v", want, structured)
+	}
+}
+
+func TestInjectManyResponsesBody(t *testing.T) {
+	p := &natsRequestReply{injectResponseAs: natsRRInjectBody}
+	msg := service.NewMessage(nil)
+
+	replies := []natsReply{
+		{Data: []byte(`{"n":1}`)},
+		{Data: []byte(`{"n":2}`)},
+	}
+	if err := p.injectManyResponses(msg, replies); err != nil {
+		t.Fatal(err)
+	}
+
+	structured, err := msg.AsStructured()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{"n": float64(1)},
+		map[string]any{"n": float64(2)},
+	}
+	if !reflect.DeepEqual(structured, want) {
+		t.Fatalf("expected %v, got %v", want, structured)
+	}
+}
+
+func TestInjectManyResponsesMetadata(t *testing.T) {
+	p := &natsRequestReply{injectResponseAs: natsRRInjectMetadata}
+	msg := service.NewMessage(nil)
+
+	replies := []natsReply{
+		{Data: []byte("a"), Header: nats.Header{"X-From": []string{"one"}}},
+		{Data: []byte("b"), Header: nats.Header{"X-From": []string{"two"}}},
+	}
+	if err := p.injectManyResponses(msg, replies); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := msg.MetaGet("nats_request_reply_0_X-From"); !ok || v != "one" {
+		t.Errorf("expected reply 0 header metadata %q, got %q (ok=%v)", "one", v, ok)
+	}
+	if v, ok := msg.MetaGet("nats_request_reply_1_body"); !ok || v != "b" {
+		t.Errorf("expected reply 1 body metadata %q, got %q (ok=%v)", "b", v, ok)
+	}
+	if v, ok := msg.MetaGet("nats_request_reply_count"); !ok || v != "2" {
+		t.Errorf("expected reply count metadata %q, got %q (ok=%v)", "2", v, ok)
+	}
+}
@@ -2,10 +2,12 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
 	"sync"
 	"time"
@@ -14,9 +16,21 @@ import (
 )
 
 const (
-	natsFieldMessageDedupeID = "msg_id"
-	natsFieldAckWait         = "ack_wait"
-	natsFieldBatching        = "batching"
+	natsFieldMessageDedupeID   = "msg_id"
+	natsFieldAckWait           = "ack_wait"
+	natsFieldBatching          = "batching"
+	natsFieldSubjectFromHeader = "subject_from_header"
+	natsFieldRoutes            = "routes"
+	natsFieldRouteWhen         = "when"
+	natsFieldRouteSubject      = "subject"
+	natsFieldRouteStream       = "stream"
+	natsFieldPublishTimeout    = "publish_timeout"
+	natsFieldMaxInFlightBytes  = "max_in_flight_bytes"
+
+	natsHeaderExpectedStream = "Nats-Expected-Stream"
+
+	natsMaxPublishRetries   = 3
+	natsPublishRetryBackoff = 100 * time.Millisecond
 )
 
 func natsJetStreamOutputConfig() *service.ConfigSpec {
@@ -49,8 +63,30 @@ func natsJetStreamOutputConfig() *service.ConfigSpec {
 				Optional(),
 		).
 		Field(service.NewDurationField(natsFieldAckWait).
-			Description("Maximum time to wait for receiving publish acknowledgements.").
-			Default("2s").
+			Description("Maximum time to wait for receiving publish acknowledgements. Deprecated: use `"+natsFieldPublishTimeout+"` instead, which applies per message rather than to the whole batch. When `"+natsFieldPublishTimeout+"` is left unset, an explicitly configured `"+natsFieldAckWait+"` is used as its default.").
+			Optional().
+			Deprecated()).
+		Field(service.NewDurationField(natsFieldPublishTimeout).
+			Description("Maximum time to wait for a single message's publish acknowledgement before it is retried or, once retries are exhausted, failed. Replaces `"+natsFieldAckWait+"`, which blocked on the whole batch at once and lost track of which messages had actually acked. Defaults to `"+natsFieldAckWait+"` when that field is set, or 5s otherwise.").
+			Optional()).
+		Field(service.NewIntField(natsFieldMaxInFlightBytes).
+			Description("An optional cap on the total size of messages awaiting a publish acknowledgement at once. When set, publishing blocks once the cap is reached until an earlier message's acknowledgement frees up budget.").
+			Optional()).
+		Field(service.NewStringField(natsFieldSubjectFromHeader).
+			Description("An optional metadata key whose value, when present on a message, overrides the interpolated `subject` for that message.").
+			Example("kafka_topic").
+			Optional()).
+		Field(service.NewObjectListField(natsFieldRoutes,
+			service.NewBloblangField(natsFieldRouteWhen).
+				Description("A Bloblang query that determines whether this route applies to a given message."),
+			service.NewInterpolatedStringField(natsFieldRouteSubject).
+				Description("The subject to publish the message to when this route matches."),
+			service.NewStringField(natsFieldRouteStream).
+				Description("An optional stream name the message is expected to land on, enforced via the `"+natsHeaderExpectedStream+"` header.").
+				Optional(),
+		).
+			Description("An optional list of routing rules evaluated per message, in order. The first matching rule overrides the `subject` (and optionally the destination stream) for that message. Evaluated after `"+natsFieldSubjectFromHeader+"`.").
+			Default([]any{}).
 			Optional()).
 		Fields(service.NewBatchPolicyField(natsFieldBatching)).
 		Fields(connectionTailFields()...).
@@ -81,6 +117,55 @@ func init() {
 
 //------------------------------------------------------------------------------
 
+// routeRule overrides the destination subject (and optionally stream) for
+// messages matched by when.
+type routeRule struct {
+	when    *bloblang.Executor
+	subject *service.InterpolatedString
+	stream  string
+}
+
+// byteSemaphore bounds the total size of a set of concurrently in-flight
+// items. A max of 0 disables the bound entirely.
+type byteSemaphore struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	max   int64
+	inUse int64
+}
+
+func newByteSemaphore(max int64) *byteSemaphore {
+	s := &byteSemaphore{max: max}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *byteSemaphore) acquire(n int64) {
+	if s.max <= 0 {
+		return
+	}
+	s.mu.Lock()
+	for s.inUse > 0 && s.inUse+n > s.max {
+		s.cond.Wait()
+	}
+	s.inUse += n
+	s.mu.Unlock()
+}
+
+func (s *byteSemaphore) release(n int64) {
+	if s.max <= 0 {
+		return
+	}
+	s.mu.Lock()
+	s.inUse -= n
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func isRetryablePublishErr(err error) bool {
+	return errors.Is(err, jetstream.ErrNoStreamResponse) || errors.Is(err, nats.ErrTimeout)
+}
+
 type jetStreamOutput struct {
 	connDetails            connectionDetails
 	subjectStrRaw          string
@@ -89,6 +174,11 @@ type jetStreamOutput struct {
 	metaFilter             *service.MetadataFilter
 	messageDeduplicationID *service.InterpolatedString
 	ackWait                time.Duration
+	publishTimeout         time.Duration
+	maxInFlightBytes       int64
+	subjectFromHeader      string
+	routes                 []routeRule
+	sem                    *byteSemaphore
 
 	log *service.Logger
 
@@ -139,13 +229,101 @@ func newJetStreamWriterFromConfig(conf *service.ParsedConfig, mgr *service.Resou
 		}
 	}
 
-	if j.ackWait, err = conf.FieldDuration(natsFieldAckWait); err != nil {
-		return nil, err
+	var ackWaitSet bool
+	if conf.Contains(natsFieldAckWait) {
+		if j.ackWait, err = conf.FieldDuration(natsFieldAckWait); err != nil {
+			return nil, err
+		}
+		ackWaitSet = true
+	}
+
+	if conf.Contains(natsFieldPublishTimeout) {
+		if j.publishTimeout, err = conf.FieldDuration(natsFieldPublishTimeout); err != nil {
+			return nil, err
+		}
+	} else if ackWaitSet {
+		j.publishTimeout = j.ackWait
+	} else {
+		j.publishTimeout = 5 * time.Second
+	}
+
+	if conf.Contains(natsFieldMaxInFlightBytes) {
+		var maxInFlightBytes int
+		if maxInFlightBytes, err = conf.FieldInt(natsFieldMaxInFlightBytes); err != nil {
+			return nil, err
+		}
+		j.maxInFlightBytes = int64(maxInFlightBytes)
+	}
+
+	if conf.Contains(natsFieldSubjectFromHeader) {
+		if j.subjectFromHeader, err = conf.FieldString(natsFieldSubjectFromHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Contains(natsFieldRoutes) {
+		routeConfs, err := conf.FieldObjectList(natsFieldRoutes)
+		if err != nil {
+			return nil, err
+		}
+		for _, routeConf := range routeConfs {
+			var rule routeRule
+			if rule.when, err = routeConf.FieldBloblang(natsFieldRouteWhen); err != nil {
+				return nil, err
+			}
+			if rule.subject, err = routeConf.FieldInterpolatedString(natsFieldRouteSubject); err != nil {
+				return nil, err
+			}
+			if routeConf.Contains(natsFieldRouteStream) {
+				if rule.stream, err = routeConf.FieldString(natsFieldRouteStream); err != nil {
+					return nil, err
+				}
+			}
+			j.routes = append(j.routes, rule)
+		}
 	}
 
+	j.sem = newByteSemaphore(j.maxInFlightBytes)
+
 	return &j, nil
 }
 
+// resolveSubject determines the destination subject (and optional expected
+// stream) for msg, preferring subject_from_header, then the first matching
+// route, falling back to the interpolated subject field.
+func (j *jetStreamOutput) resolveSubject(msg *service.Message) (subject, stream string, err error) {
+	if j.subjectFromHeader != "" {
+		if v, ok := msg.MetaGet(j.subjectFromHeader); ok && v != "" {
+			return v, "", nil
+		}
+	}
+
+	for _, rule := range j.routes {
+		resMsg, err := msg.BloblangQuery(rule.when)
+		if err != nil {
+			return "", "", fmt.Errorf("route condition evaluation: %w", err)
+		}
+		matched, err := resMsg.AsStructured()
+		if err != nil {
+			return "", "", fmt.Errorf("route condition result: %w", err)
+		}
+		if ok, _ := matched.(bool); !ok {
+			continue
+		}
+
+		if subject, err = rule.subject.TryString(msg); err != nil {
+			return "", "", fmt.Errorf(`failed string interpolation on route field "subject": %w`, err)
+		}
+		return subject, rule.stream, nil
+	}
+
+	subject, err = j.subjectStr.TryString(msg)
+	if err != nil {
+		return "", "", fmt.Errorf(`failed string interpolation on field "subject": %w`, err)
+	}
+	return subject, "", nil
+}
+
 //------------------------------------------------------------------------------
 
 func (j *jetStreamOutput) Connect(ctx context.Context) (err error) {
@@ -191,6 +369,45 @@ func (j *jetStreamOutput) disconnect() {
 
 //------------------------------------------------------------------------------
 
+// publishWithRetry fires jsmsg and waits for its ack, retrying a bounded
+// number of times with exponential backoff on transient errors such as a
+// stream leader re-election.
+func publishWithRetry(ctx context.Context, js jetstream.JetStream, jsmsg *nats.Msg, dedupeID string, timeout time.Duration) error {
+	backoff := natsPublishRetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		future, err := js.PublishMsgAsync(jsmsg, jetstream.WithMsgID(dedupeID))
+		if err != nil {
+			return err
+		}
+
+		var retry bool
+		var publishErr error
+		select {
+		case <-future.Ok():
+			return nil
+		case publishErr = <-future.Err():
+			retry = isRetryablePublishErr(publishErr)
+		case <-time.After(timeout):
+			publishErr = fmt.Errorf("publish took too long")
+			retry = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if !retry || attempt >= natsMaxPublishRetries {
+			return publishErr
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
 func (j *jetStreamOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
 	j.connMut.Lock()
 	js := j.js
@@ -199,10 +416,14 @@ func (j *jetStreamOutput) WriteBatch(ctx context.Context, batch service.MessageB
 		return service.ErrNotConnected
 	}
 
+	var wg sync.WaitGroup
+	var batchErrMut sync.Mutex
+	var batchErr *service.BatchError
+
 	for i, msg := range batch {
-		subject, err := j.subjectStr.TryString(msg)
+		subject, stream, err := j.resolveSubject(msg)
 		if err != nil {
-			return fmt.Errorf(`failed string interpolation on field "subject": %w`, err)
+			return err
 		}
 
 		var dedupeID string
@@ -232,17 +453,30 @@ func (j *jetStreamOutput) WriteBatch(ctx context.Context, batch service.MessageB
 			jsmsg.Header.Add(key, value)
 			return nil
 		})
-
-		_, err = js.PublishMsgAsync(jsmsg, jetstream.WithMsgID(dedupeID))
-		if err != nil {
-			return err
+		if stream != "" {
+			jsmsg.Header.Set(natsHeaderExpectedStream, stream)
 		}
+
+		size := int64(len(msgBytes))
+		j.sem.acquire(size)
+
+		wg.Add(1)
+		go func(i int, jsmsg *nats.Msg, dedupeID string, size int64) {
+			defer wg.Done()
+			defer j.sem.release(size)
+
+			if err := publishWithRetry(ctx, js, jsmsg, dedupeID, j.publishTimeout); err != nil {
+				batchErrMut.Lock()
+				batchErr = batchErr.Failed(i, err)
+				batchErrMut.Unlock()
+			}
+		}(i, jsmsg, dedupeID, size)
 	}
 
-	select {
-	case <-js.PublishAsyncComplete():
-	case <-time.After(j.ackWait):
-		return fmt.Errorf("publish took too long")
+	wg.Wait()
+
+	if batchErr != nil {
+		return batchErr
 	}
 	return nil
 }
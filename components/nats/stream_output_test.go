@@ -0,0 +1,166 @@
+package nats
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestResolveSubjectFromHeader(t *testing.T) {
+	subjectStr, err := service.NewInterpolatedString("default.subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &jetStreamOutput{
+		subjectFromHeader: "target_subject",
+		subjectStr:        subjectStr,
+	}
+
+	msg := service.NewMessage(nil)
+	msg.MetaSetMut("target_subject", "from.header")
+
+	subject, stream, err := j.resolveSubject(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "from.header" {
+		t.Errorf("expected subject %q, got %q", "from.header", subject)
+	}
+	if stream != "" {
+		t.Errorf("expected no stream override, got %q", stream)
+	}
+}
+
+func TestResolveSubjectRouteMatch(t *testing.T) {
+	subjectStr, err := service.NewInterpolatedString("default.subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	when, err := bloblang.Parse(`root = this.kind == "priority"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routeSubject, err := service.NewInterpolatedString("priority.subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &jetStreamOutput{
+		subjectStr: subjectStr,
+		routes: []routeRule{
+			{when: when, subject: routeSubject, stream: "PRIORITY"},
+		},
+	}
+
+	msg := service.NewMessage([]byte(`{"kind":"priority"}`))
+
+	subject, stream, err := j.resolveSubject(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "priority.subject" {
+		t.Errorf("expected subject %q, got %q", "priority.subject", subject)
+	}
+	if stream != "PRIORITY" {
+		t.Errorf("expected stream %q, got %q", "PRIORITY", stream)
+	}
+}
+
+func TestResolveSubjectFallback(t *testing.T) {
+	subjectStr, err := service.NewInterpolatedString("default.subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	when, err := bloblang.Parse(`root = this.kind == "priority"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routeSubject, err := service.NewInterpolatedString("priority.subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &jetStreamOutput{
+		subjectStr: subjectStr,
+		routes: []routeRule{
+			{when: when, subject: routeSubject},
+		},
+	}
+
+	msg := service.NewMessage([]byte(`{"kind":"normal"}`))
+
+	subject, stream, err := j.resolveSubject(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "default.subject" {
+		t.Errorf("expected subject %q, got %q", "default.subject", subject)
+	}
+	if stream != "" {
+		t.Errorf("expected no stream override, got %q", stream)
+	}
+}
+
+func TestIsRetryablePublishErr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no stream response", jetstream.ErrNoStreamResponse, true},
+		{"timeout", nats.ErrTimeout, true},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, c := range cases {
+		if got := isRetryablePublishErr(c.err); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestByteSemaphoreBlocksUntilRelease(t *testing.T) {
+	sem := newByteSemaphore(10)
+
+	sem.acquire(10)
+
+	acquired := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sem.acquire(5)
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected second acquire to block while budget is exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sem.release(10)
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected second acquire to unblock after release")
+	}
+
+	wg.Wait()
+}
+
+func TestByteSemaphoreDisabledWhenZero(t *testing.T) {
+	sem := newByteSemaphore(0)
+
+	// With no budget configured, acquire must never block regardless of size.
+	sem.acquire(1 << 30)
+	sem.release(1 << 30)
+}